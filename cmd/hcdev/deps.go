@@ -0,0 +1,359 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+// defines the deps.json dependency manifest format and the resolver behind
+// `hcdev deps`: reproducible, hash-pinned vendoring of zomes from other DNAs
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	holo "github.com/metacurrency/holochain"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DepsFileName is the manifest listing external DNA dependencies, read and
+// written by `hcdev deps` out of the top of the app's dev directory.
+const DepsFileName = "deps.json"
+
+// Dependency is one external DNA source: where to fetch it from (a local
+// path, or a git URL at a ref) and the content hash it must resolve to once
+// vendored, so drift is caught instead of silently picked up.
+type Dependency struct {
+	Name string `json:"Name"`
+	URL  string `json:"URL"`
+	Ref  string `json:"Ref,omitempty"`
+	Hash string `json:"Hash,omitempty"`
+}
+
+// DepsManifest is the parsed form of deps.json.
+type DepsManifest struct {
+	Dependencies []Dependency `json:"Dependencies"`
+}
+
+func depsManifestPath(devPath string) string {
+	return filepath.Join(devPath, DepsFileName)
+}
+
+func vendorDir(devPath string) string {
+	return filepath.Join(devPath, holo.ChainDNADir, "vendor")
+}
+
+// LoadDepsManifest reads deps.json from devPath. A missing manifest is not
+// an error; it is treated as a manifest with no dependencies.
+func LoadDepsManifest(devPath string) (m *DepsManifest, err error) {
+	data, err := ioutil.ReadFile(depsManifestPath(devPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &DepsManifest{}, nil
+		}
+		return
+	}
+	m = &DepsManifest{}
+	err = json.Unmarshal(data, m)
+	return
+}
+
+// Save writes the manifest back out to devPath/deps.json.
+func (m *DepsManifest) Save(devPath string) (err error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return
+	}
+	return ioutil.WriteFile(depsManifestPath(devPath), data, os.ModePerm)
+}
+
+// splitURLRef splits a "<url>@<ref>" spec into its URL and ref. The "@" only
+// delimits a ref when it appears after the last "/" in spec; this keeps it
+// from being confused with the user-info "@" in an SSH git URL like
+// git@github.com:org/repo.git, which has no ref suffix at all.
+func splitURLRef(spec string) (url, ref string) {
+	slash := strings.LastIndex(spec, "/")
+	at := strings.LastIndex(spec, "@")
+	if at > slash {
+		return spec[:at], spec[at+1:]
+	}
+	return spec, ""
+}
+
+// depsAdd parses a "<url>@<ref>" spec, appends it to the manifest as name,
+// and syncs it immediately so the vendored copy and its hash are up to date.
+func depsAdd(service *holo.Service, devPath, name, spec string) (err error) {
+	url, ref := splitURLRef(spec)
+
+	m, err := LoadDepsManifest(devPath)
+	if err != nil {
+		return
+	}
+	for _, d := range m.Dependencies {
+		if d.Name == name {
+			return fmt.Errorf("deps: dependency %s already exists, remove it from %s first", name, DepsFileName)
+		}
+	}
+	m.Dependencies = append(m.Dependencies, Dependency{Name: name, URL: url, Ref: ref})
+	err = m.Save(devPath)
+	if err != nil {
+		return
+	}
+	return depsSync(service, devPath)
+}
+
+// depsSync fetches (or copies, for local-path deps) every dependency into
+// dna/vendor/<name>/, verifying its hash as it goes and recording a freshly
+// computed hash for any dependency that doesn't have one pinned yet.
+func depsSync(service *holo.Service, devPath string) (err error) {
+	m, err := LoadDepsManifest(devPath)
+	if err != nil {
+		return
+	}
+	changed := false
+	for i, d := range m.Dependencies {
+		dest := filepath.Join(vendorDir(devPath), d.Name)
+		err = fetchDependency(service, devPath, d, dest)
+		if err != nil {
+			return
+		}
+
+		var hash string
+		hash, err = hashDir(dest)
+		if err != nil {
+			return
+		}
+
+		if d.Hash == "" {
+			m.Dependencies[i].Hash = hash
+			changed = true
+		} else if d.Hash != hash {
+			return fmt.Errorf("deps: dependency %s hash mismatch: manifest has %s, vendored copy hashes to %s", d.Name, d.Hash, hash)
+		}
+
+		err = exposeVendoredZomes(devPath, d.Name, dest)
+		if err != nil {
+			return
+		}
+		fmt.Printf("synced dependency %s -> %s (%s)\n", d.Name, dest, hash)
+	}
+	if changed {
+		err = m.Save(devPath)
+	}
+	return
+}
+
+// depsVerify re-hashes every vendored dependency and fails on the first one
+// that has drifted from the hash pinned in the manifest.
+func depsVerify(devPath string) (err error) {
+	m, err := LoadDepsManifest(devPath)
+	if err != nil {
+		return
+	}
+	for _, d := range m.Dependencies {
+		dest := filepath.Join(vendorDir(devPath), d.Name)
+		var hash string
+		hash, err = hashDir(dest)
+		if err != nil {
+			return fmt.Errorf("deps: dependency %s not vendored: %v", d.Name, err)
+		}
+		if hash != d.Hash {
+			return fmt.Errorf("deps: dependency %s has drifted: manifest pins %s, vendored copy hashes to %s", d.Name, d.Hash, hash)
+		}
+		fmt.Printf("dependency %s OK (%s)\n", d.Name, hash)
+	}
+	return
+}
+
+// depsVendor (re)populates the app's dna.json with the zomes of whatever is
+// already sitting in dna/vendor/, without touching the network: unlike sync
+// it does not fetch dependencies or re-verify their hashes, so it's safe to
+// run offline after a clone that already brought its vendored copies along.
+func depsVendor(devPath string) (err error) {
+	m, err := LoadDepsManifest(devPath)
+	if err != nil {
+		return
+	}
+	for _, d := range m.Dependencies {
+		dest := filepath.Join(vendorDir(devPath), d.Name)
+		if _, statErr := os.Stat(dest); statErr != nil {
+			return fmt.Errorf("deps: dependency %s is not vendored at %s; run 'hcdev deps sync' first", d.Name, dest)
+		}
+		err = exposeVendoredZomes(devPath, d.Name, dest)
+		if err != nil {
+			return
+		}
+		fmt.Printf("vendored dependency %s exposed from %s\n", d.Name, dest)
+	}
+	return
+}
+
+// fetchDependency materializes one dependency into dest. Local-path
+// dependencies are cloned via service.Clone, the same mechanism `hcdev init
+// --clone` uses; git/URL dependencies are fetched directly with the `git`
+// binary on $PATH (clone, then checkout Ref if one was given), and the
+// checkout's .git directory is stripped so only the vendored sources are
+// hashed and kept.
+func fetchDependency(service *holo.Service, devPath string, d Dependency, dest string) (err error) {
+	err = os.RemoveAll(dest)
+	if err != nil {
+		return
+	}
+
+	if isLocalPath(d.URL) {
+		var info os.FileInfo
+		info, err = os.Stat(d.URL)
+		if err != nil {
+			return
+		}
+		if !info.Mode().IsDir() {
+			return errors.New("deps: local dependency path must be a directory")
+		}
+		var agent holo.Agent
+		agent, err = holo.LoadAgent(rootPath)
+		if err != nil {
+			return
+		}
+		return service.Clone(d.URL, dest, agent, true)
+	}
+
+	if strings.HasPrefix(d.URL, "-") || strings.HasPrefix(d.Ref, "-") {
+		return fmt.Errorf("deps: dependency %s: URL and Ref must not start with '-'", d.Name)
+	}
+
+	err = os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return
+	}
+	args := []string{"clone", "--quiet", "--", d.URL, dest}
+	cloneCmd := exec.Command("git", args...)
+	cloneCmd.Stdout = os.Stdout
+	cloneCmd.Stderr = os.Stderr
+	err = cloneCmd.Run()
+	if err != nil {
+		return
+	}
+	if d.Ref != "" {
+		checkoutCmd := exec.Command("git", "-C", dest, "checkout", "--quiet", d.Ref)
+		checkoutCmd.Stdout = os.Stdout
+		checkoutCmd.Stderr = os.Stderr
+		err = checkoutCmd.Run()
+		if err != nil {
+			return
+		}
+	}
+	return os.RemoveAll(filepath.Join(dest, ".git"))
+}
+
+// exposeVendoredZomes merges the zomes defined by a vendored dependency's own
+// dna.json into the app's dna.json, namespaced as "<depName>.<zomeName>" so
+// zomes from different dependencies can't collide, and with CodeFile
+// rewritten to point at the vendored copy under dna/vendor/<depName>/. This
+// is what makes a synced dependency actually usable by the app's build,
+// rather than just a hashed, inert copy on disk.
+//
+// A dependency that doesn't ship its own dna.json (e.g. one that's just a
+// library of zome code) is left alone; there's nothing to merge.
+func exposeVendoredZomes(devPath, depName, dest string) (err error) {
+	depDNAPath := filepath.Join(dest, holo.ChainDNADir, "dna.json")
+	depDNAJSON, err := ioutil.ReadFile(depDNAPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var depDNA holo.DNA
+	err = json.Unmarshal(depDNAJSON, &depDNA)
+	if err != nil {
+		return
+	}
+	if len(depDNA.Zomes) == 0 {
+		return
+	}
+
+	appDNAPath := filepath.Join(devPath, holo.ChainDNADir, "dna.json")
+	var appDNAJSON []byte
+	appDNAJSON, err = ioutil.ReadFile(appDNAPath)
+	if err != nil {
+		return
+	}
+	var appDNA holo.DNA
+	err = json.Unmarshal(appDNAJSON, &appDNA)
+	if err != nil {
+		return
+	}
+
+	haveZome := make(map[string]bool, len(appDNA.Zomes))
+	for _, z := range appDNA.Zomes {
+		haveZome[z.Name] = true
+	}
+
+	changed := false
+	for _, zome := range depDNA.Zomes {
+		zome.Name = depName + "." + zome.Name
+		if haveZome[zome.Name] {
+			continue
+		}
+		zome.CodeFile = filepath.Join("vendor", depName, holo.ChainDNADir, zome.CodeFile)
+		appDNA.Zomes = append(appDNA.Zomes, zome)
+		haveZome[zome.Name] = true
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	var out []byte
+	out, err = json.MarshalIndent(appDNA, "", "  ")
+	if err != nil {
+		return
+	}
+	return ioutil.WriteFile(appDNAPath, out, os.ModePerm)
+}
+
+func isLocalPath(url string) bool {
+	return strings.HasPrefix(url, "/") || strings.HasPrefix(url, "./") || strings.HasPrefix(url, "../")
+}
+
+// hashDir computes a stable content hash over every file under dir so the
+// same dependency checked out twice hashes identically regardless of
+// filesystem walk order.
+func hashDir(dir string) (hash string, err error) {
+	h := sha256.New()
+	var paths []string
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, werr error) error {
+		if werr != nil {
+			return werr
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, rerr := filepath.Rel(dir, p)
+		if rerr != nil {
+			return rerr
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	sort.Strings(paths)
+	for _, rel := range paths {
+		var content []byte
+		content, err = ioutil.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(h, "%s\x00", rel)
+		h.Write(content)
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+	return
+}
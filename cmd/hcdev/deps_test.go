@@ -0,0 +1,74 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitURLRef(t *testing.T) {
+	cases := []struct {
+		spec    string
+		wantURL string
+		wantRef string
+	}{
+		{"https://github.com/org/repo.git@v1.2.3", "https://github.com/org/repo.git", "v1.2.3"},
+		{"https://github.com/org/repo.git", "https://github.com/org/repo.git", ""},
+		{"git@github.com:org/repo.git", "git@github.com:org/repo.git", ""},
+		{"../other-app@myref", "../other-app", "myref"},
+		{"../other-app", "../other-app", ""},
+	}
+	for _, c := range cases {
+		url, ref := splitURLRef(c.spec)
+		if url != c.wantURL || ref != c.wantRef {
+			t.Errorf("splitURLRef(%q) = (%q, %q), want (%q, %q)", c.spec, url, ref, c.wantURL, c.wantRef)
+		}
+	}
+}
+
+func TestHashDirIsStableAndDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("bbb"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	h1, err := hashDir(dir)
+	if err != nil {
+		t.Fatalf("hashDir: %v", err)
+	}
+	h2, err := hashDir(dir)
+	if err != nil {
+		t.Fatalf("hashDir: %v", err)
+	}
+	if h1 != h2 {
+		t.Errorf("hashDir is not stable: %s != %s", h1, h2)
+	}
+
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "a.txt"), []byte("aaa"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(other, "sub"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(other, "sub", "b.txt"), []byte("changed"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	h3, err := hashDir(other)
+	if err != nil {
+		t.Fatalf("hashDir: %v", err)
+	}
+	if h3 == h1 {
+		t.Errorf("hashDir did not detect changed content: got same hash %s", h3)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+// implements `hcdev dump`: renders chain or DHT state as text or JSON
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	holo "github.com/metacurrency/holochain"
+)
+
+// chainDumpRecord is one entry of the `--chain --format json` output. Kind is
+// always "chain" so the array can later be merged with DHT records and still
+// be told apart by downstream tooling.
+type chainDumpRecord struct {
+	Kind     string      `json:"Kind"`
+	Type     string      `json:"Type"`
+	Time     string      `json:"Time"`
+	Hash     string      `json:"Hash"`
+	Previous string      `json:"Previous"`
+	Sig      string      `json:"Signature"`
+	Entry    interface{} `json:"Entry"`
+}
+
+// dhtDumpRecord is one message in the DHT's gossip changelog, as emitted by
+// `--dht --format json`. Idx is the message's position in that changelog
+// (as used for gossip replay), so records are naturally ordered and a
+// downstream tool can resume from a given Idx.
+type dhtDumpRecord struct {
+	Idx  int         `json:"Idx"`
+	Type string      `json:"Type"`
+	Time string      `json:"Time"`
+	Body interface{} `json:"Body"`
+}
+
+// dumpChain renders h's local chain, oldest entry first, as either a plain
+// text report or a JSON array of chainDumpRecord.
+func dumpChain(h *holo.Holochain, format string) (result string, err error) {
+	var records []chainDumpRecord
+	err = h.Chain().Walk(func(key *holo.Hash, header *holo.Header, entry holo.Entry) (err error) {
+		records = append(records, chainDumpRecord{
+			Kind:     "chain",
+			Type:     header.Type,
+			Time:     header.Time.String(),
+			Hash:     key.String(),
+			Previous: header.HeaderLink.String(),
+			Sig:      fmt.Sprintf("%v", header.Sig),
+			Entry:    entry.Content(),
+		})
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	if format == "json" {
+		var b []byte
+		b, err = json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return
+		}
+		result = string(b)
+		return
+	}
+
+	for _, r := range records {
+		result += fmt.Sprintf("%s  %s\n  hash: %s\n  prev: %s\n  sig:  %s\n  entry: %v\n\n",
+			r.Type, r.Time, r.Hash, r.Previous, r.Sig, r.Entry)
+	}
+	return
+}
+
+// dumpDHT renders the puts, links and metadata this node's DHT holds by
+// replaying its gossip changelog from index 1 through its current index
+// (the same mechanism the gossip protocol itself uses to catch up a peer),
+// as either a plain text report or a JSON array of dhtDumpRecord.
+func dumpDHT(h *holo.Holochain, format string) (result string, err error) {
+	dht := h.DHT()
+
+	var idx int
+	idx, err = dht.GetIdx()
+	if err != nil {
+		return
+	}
+
+	records := make([]dhtDumpRecord, 0, idx)
+	for i := 1; i <= idx; i++ {
+		var msg holo.Message
+		msg, err = dht.GetIdxMessage(i)
+		if err != nil {
+			return
+		}
+		records = append(records, dhtDumpRecord{
+			Idx:  i,
+			Type: fmt.Sprintf("%v", msg.Type),
+			Time: msg.Time.String(),
+			Body: msg.Body,
+		})
+	}
+
+	if format == "json" {
+		var b []byte
+		b, err = json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return
+		}
+		result = string(b)
+		return
+	}
+
+	for _, r := range records {
+		result += fmt.Sprintf("%d  %s  %s\n  %v\n\n", r.Idx, r.Type, r.Time, r.Body)
+	}
+	return
+}
@@ -6,12 +6,14 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	holo "github.com/metacurrency/holochain"
 	"github.com/metacurrency/holochain/cmd"
 	"github.com/metacurrency/holochain/ui"
 	"github.com/urfave/cli"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path"
@@ -24,7 +26,10 @@ const (
 )
 
 var debug, appInitialized bool
-var rootPath, devPath, name string
+var rootPath, cachePath, devPath, name string
+var dhtBasePort int
+var enableMDNS bool
+var bootstrapServer string
 
 func setupApp() (app *cli.App) {
 	app = cli.NewApp()
@@ -50,10 +55,28 @@ func setupApp() (app *cli.App) {
 			Usage:       "path to chain source definition directory (default: current working dir)",
 			Destination: &devPath,
 		},
+		cli.IntFlag{
+			Name:        "DHTport",
+			Usage:       "base port for DHT listeners (each scenario role/node increments from this)",
+			Value:       6001,
+			Destination: &dhtBasePort,
+		},
+		cli.BoolFlag{
+			Name:        "mdns",
+			Usage:       "enable mDNS discovery between nodes",
+			Destination: &enableMDNS,
+		},
+		cli.StringFlag{
+			Name:        "bootstrap",
+			Usage:       "bootstrap server address for node discovery",
+			Destination: &bootstrapServer,
+		},
 	}
 
 	var interactive bool
 	var clonePath, scaffoldPath string
+	var dumpChainFlag, dumpDHTFlag bool
+	var dumpFormat string
 	app.Commands = []cli.Command{
 		{
 			Name:    "init",
@@ -128,8 +151,20 @@ func setupApp() (app *cli.App) {
 					if !info.Mode().IsRegular() {
 						return errors.New("expecting a scaffold file")
 					}
+					f, err := os.Open(scaffoldPath)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					scaffold, err := LoadScaffold(f)
+					if err != nil {
+						return err
+					}
 					fmt.Printf("initializing from scaffold:%s\n", scaffoldPath)
-					fmt.Printf("WARNING: NOT IMPLEMENTED\n")
+					err = scaffold.Init(devPath, filepath.Dir(scaffoldPath))
+					if err != nil {
+						return err
+					}
 				} else {
 					// build empty app directory template
 					err := os.MkdirAll(devPath, os.ModePerm)
@@ -219,8 +254,148 @@ func setupApp() (app *cli.App) {
 					return errors.New("missing scenario name argument")
 				}
 
-				// terminates go process
-				cmd.ExecBinScript("holochain.app.testScenario", args[0])
+				results, err := runScenario(service, devPath, rootPath, cachePath, args[0], dhtBasePort, enableMDNS, bootstrapServer)
+				if err != nil {
+					return err
+				}
+
+				var failed bool
+				for _, r := range results {
+					status := "PASS"
+					if !r.Passed {
+						status = "FAIL"
+						failed = true
+					}
+					fmt.Printf("role %s: %s\n", r.Role, status)
+					for _, e := range r.Errors {
+						fmt.Printf("  %s\n", e)
+					}
+				}
+				if failed {
+					return errors.New("scenario: one or more roles failed")
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "deps",
+			Usage: "manage external DNA dependencies vendored via deps.json",
+			Subcommands: []cli.Command{
+				{
+					Name:      "add",
+					Usage:     "add a dependency and sync it",
+					ArgsUsage: "<name> <url>@<ref>",
+					Action: func(c *cli.Context) error {
+						args := c.Args()
+						if len(args) != 2 {
+							return errors.New("deps add: expecting <name> <url>@<ref>")
+						}
+						return depsAdd(service, devPath, args[0], args[1])
+					},
+				},
+				{
+					Name:  "sync",
+					Usage: "fetch and hash-verify every dependency into dna/vendor",
+					Action: func(c *cli.Context) error {
+						return depsSync(service, devPath)
+					},
+				},
+				{
+					Name:  "verify",
+					Usage: "re-hash vendored dependencies and fail on drift",
+					Action: func(c *cli.Context) error {
+						return depsVerify(devPath)
+					},
+				},
+				{
+					Name:  "vendor",
+					Usage: "merge zomes already in dna/vendor into dna.json, without fetching",
+					Action: func(c *cli.Context) error {
+						return depsVendor(devPath)
+					},
+				},
+			},
+		},
+		{
+			Name:      "dump",
+			Usage:     "dump the chain or DHT state as text or JSON",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:        "chain",
+					Usage:       "dump the local chain",
+					Destination: &dumpChainFlag,
+				},
+				cli.BoolFlag{
+					Name:        "dht",
+					Usage:       "dump the DHT entries, links and metadata held by this node",
+					Destination: &dumpDHTFlag,
+				},
+				cli.StringFlag{
+					Name:        "format",
+					Usage:       "output format: string (default) or json",
+					Value:       "string",
+					Destination: &dumpFormat,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if dumpChainFlag == dumpDHTFlag {
+					return errors.New("dump: please specify exactly one of --chain or --dht")
+				}
+				if dumpFormat != "string" && dumpFormat != "json" {
+					return errors.New("dump: --format must be 'string' or 'json'")
+				}
+
+				h, err := getHolochain(c, service)
+				if err != nil {
+					return err
+				}
+
+				var out string
+				if dumpChainFlag {
+					out, err = dumpChain(h, dumpFormat)
+				} else {
+					// the DHT isn't populated until the chain is activated
+					err = h.Activate()
+					if err != nil {
+						return err
+					}
+					out, err = dumpDHT(h, dumpFormat)
+				}
+				if err != nil {
+					return err
+				}
+				fmt.Println(out)
+				return nil
+			},
+		},
+		{
+			Name:      "scaffold",
+			Usage:     "dump the current app as a scaffold file for round-tripping to another machine",
+			ArgsUsage: "<path to write scaffold file>",
+			Action: func(c *cli.Context) error {
+				if !appInitialized {
+					return errors.New("please initialize this app with 'hcdev init'")
+				}
+
+				args := c.Args()
+				if len(args) != 1 {
+					return errors.New("scaffold: expecting path to output scaffold file as single argument")
+				}
+
+				scaffold, err := DumpScaffold(devPath)
+				if err != nil {
+					return err
+				}
+				data, err := json.MarshalIndent(scaffold, "", "  ")
+				if err != nil {
+					return err
+				}
+				err = ioutil.WriteFile(args[0], data, os.ModePerm)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("wrote scaffold to %s\n", args[0])
 				return nil
 			},
 		},
@@ -248,6 +423,10 @@ func setupApp() (app *cli.App) {
 				}
 				fmt.Printf("Serving holochain with DNA hash:%v on port:%s\n", h.DNAHash(), port)
 
+				h.Config.DHTPort = dhtBasePort
+				h.Config.EnableMDNS = enableMDNS
+				h.Config.BootstrapServer = bootstrapServer
+
 				err = h.Activate()
 				if err != nil {
 					return err
@@ -279,19 +458,22 @@ func setupApp() (app *cli.App) {
 			appInitialized = true
 		}
 
-		if rootPath == "" {
-			rootPath = os.Getenv("HOLOPATH")
-			if rootPath == "" {
-				u, err := user.Current()
-				if err != nil {
-					return err
-				}
-				userPath := u.HomeDir
-				rootPath = userPath + "/" + holo.DefaultDirectoryName + "dev"
-			}
+		u, err := user.Current()
+		if err != nil {
+			return err
+		}
+		rootPath, cachePath, err = resolveRootPath(rootPath, u.HomeDir)
+		if err != nil {
+			return err
 		}
+
+		agentName := defaultAgentName(u.HomeDir)
+		if agentName == "" {
+			agentName = "test@example.com"
+		}
+
 		if !holo.IsInitialized(rootPath) {
-			service, err = holo.Init(rootPath, holo.AgentName("test@example.com"))
+			service, err = holo.Init(rootPath, holo.AgentName(agentName))
 			if err != nil {
 				return err
 			}
@@ -327,8 +509,8 @@ func main() {
 }
 
 func getHolochain(c *cli.Context, service *holo.Service) (h *holo.Holochain, err error) {
-	fmt.Printf("Copying chain to: %s\n", rootPath)
-	err = os.RemoveAll(rootPath + "/" + name)
+	fmt.Printf("Copying chain to: %s\n", cachePath)
+	err = os.RemoveAll(cachePath + "/" + name)
 	if err != nil {
 		return
 	}
@@ -337,11 +519,11 @@ func getHolochain(c *cli.Context, service *holo.Service) (h *holo.Holochain, err
 	if err != nil {
 		return
 	}
-	err = service.Clone(devPath, rootPath+"/"+name, agent, false)
+	err = service.Clone(devPath, cachePath+"/"+name, agent, false)
 	if err != nil {
 		return
 	}
-	h, err = service.Load(name)
+	h, err = holo.Load(cachePath + "/" + name)
 	if err != nil {
 		return
 	}
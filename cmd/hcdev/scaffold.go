@@ -0,0 +1,301 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+// defines the hcdev scaffold manifest format and the init/dump logic that
+// materializes an app directory from it (or vice-versa)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	holo "github.com/metacurrency/holochain"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ScaffoldVersion is the version of the scaffold file format written by this
+// build of hcdev. LoadScaffold refuses files with a newer version.
+const ScaffoldVersion = 1
+
+// Scaffold is the top level, single-file description of everything `hcdev
+// init` needs to materialize an app directory: the DNA (properties, zomes
+// and their entries/functions/code) plus the UI and test trees. It carries
+// no agent identity.
+// It is the format emitted by `hcdev scaffold <path>`, so an app can be
+// round-tripped between machines as one file instead of a cloned directory.
+type Scaffold struct {
+	Version int            `json:"Version"`
+	DNA     ScaffoldDNA    `json:"DNA"`
+	UI      []ScaffoldFile `json:"UI,omitempty"`
+	Test    []ScaffoldFile `json:"Test,omitempty"`
+}
+
+// ScaffoldDNA holds the subset of holo.DNA that a scaffold author specifies;
+// everything else (UUID, hashes, etc.) is generated at init time.
+type ScaffoldDNA struct {
+	Name       string            `json:"Name"`
+	Properties map[string]string `json:"Properties,omitempty"`
+	Zomes      []ScaffoldZome    `json:"Zomes"`
+}
+
+// ScaffoldZome describes one zome: its code language, entry types and
+// exposed functions. Code is either given inline or read from CodeFile
+// (resolved relative to the scaffold file itself).
+type ScaffoldZome struct {
+	Name        string             `json:"Name"`
+	Description string             `json:"Description,omitempty"`
+	NucleusType string             `json:"NucleusType"`
+	CodeFile    string             `json:"CodeFile,omitempty"`
+	Code        string             `json:"Code,omitempty"`
+	Entries     []ScaffoldEntry    `json:"Entries,omitempty"`
+	Functions   []ScaffoldFunction `json:"Functions,omitempty"`
+}
+
+// ScaffoldEntry mirrors holo.EntryDef.
+type ScaffoldEntry struct {
+	Name       string `json:"Name"`
+	DataFormat string `json:"DataFormat"`
+	Sharing    string `json:"Sharing,omitempty"`
+	Schema     string `json:"Schema,omitempty"`
+}
+
+// ScaffoldFunction mirrors holo.FunctionDef.
+type ScaffoldFunction struct {
+	Name        string `json:"Name"`
+	CallingType string `json:"CallingType"`
+	Exposure    string `json:"Exposure,omitempty"`
+}
+
+// ScaffoldFile is a single UI or test file, inlined into the manifest so the
+// whole app travels as one JSON document.
+type ScaffoldFile struct {
+	Path    string `json:"Path"`
+	Content string `json:"Content"`
+}
+
+// LoadScaffold parses a scaffold manifest from r.
+func LoadScaffold(r io.Reader) (s *Scaffold, err error) {
+	var S Scaffold
+	decoder := json.NewDecoder(r)
+	err = decoder.Decode(&S)
+	if err != nil {
+		return
+	}
+	if S.Version > ScaffoldVersion {
+		err = fmt.Errorf("scaffold file version %d is newer than supported version %d", S.Version, ScaffoldVersion)
+		return
+	}
+	s = &S
+	return
+}
+
+// Init materializes the scaffold into devPath: it creates the dna/, ui/ and
+// test/ trees, writes out each zome's code file and builds dna.json from the
+// zome, entry and function definitions. baseDir is the directory the
+// scaffold manifest itself was loaded from, against which relative
+// ScaffoldZome.CodeFile paths are resolved.
+func (s *Scaffold) Init(devPath, baseDir string) (err error) {
+	dnaPath := filepath.Join(devPath, holo.ChainDNADir)
+	uiPath := filepath.Join(devPath, holo.ChainUIDir)
+	testPath := filepath.Join(devPath, holo.ChainTestDir)
+
+	for _, dir := range []string{devPath, dnaPath, uiPath, testPath} {
+		err = os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return
+		}
+	}
+
+	dna := holo.DNA{
+		Name:       s.DNA.Name,
+		Properties: s.DNA.Properties,
+	}
+
+	for _, z := range s.DNA.Zomes {
+		code := z.Code
+		if z.CodeFile != "" {
+			codeFile := z.CodeFile
+			if !filepath.IsAbs(codeFile) {
+				codeFile = filepath.Join(baseDir, codeFile)
+			}
+			var b []byte
+			b, err = ioutil.ReadFile(codeFile)
+			if err != nil {
+				return
+			}
+			code = string(b)
+		}
+
+		codeFileName := z.Name + codeFileExtension(z.NucleusType)
+		err = ioutil.WriteFile(filepath.Join(dnaPath, codeFileName), []byte(code), os.ModePerm)
+		if err != nil {
+			return
+		}
+
+		zome := holo.Zome{
+			Name:        z.Name,
+			Description: z.Description,
+			NucleusType: z.NucleusType,
+			CodeFile:    codeFileName,
+		}
+		for _, e := range z.Entries {
+			zome.Entries = append(zome.Entries, holo.EntryDef{
+				Name:       e.Name,
+				DataFormat: e.DataFormat,
+				Sharing:    e.Sharing,
+				Schema:     e.Schema,
+			})
+		}
+		for _, f := range z.Functions {
+			zome.Functions = append(zome.Functions, holo.FunctionDef{
+				Name:        f.Name,
+				CallingType: f.CallingType,
+				Exposure:    f.Exposure,
+			})
+		}
+		dna.Zomes = append(dna.Zomes, zome)
+	}
+
+	var dnaJSON []byte
+	dnaJSON, err = json.MarshalIndent(dna, "", "  ")
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(filepath.Join(dnaPath, "dna.json"), dnaJSON, os.ModePerm)
+	if err != nil {
+		return
+	}
+
+	for _, f := range s.UI {
+		err = writeScaffoldFile(uiPath, f)
+		if err != nil {
+			return
+		}
+	}
+	for _, f := range s.Test {
+		err = writeScaffoldFile(testPath, f)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeScaffoldFile(base string, f ScaffoldFile) (err error) {
+	fullPath := filepath.Join(base, f.Path)
+	err = os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(fullPath, []byte(f.Content), os.ModePerm)
+	return
+}
+
+func codeFileExtension(nucleusType string) string {
+	switch nucleusType {
+	case holo.JSNucleusType:
+		return ".js"
+	case holo.ZygoNucleusType:
+		return ".zy"
+	default:
+		return ".code"
+	}
+}
+
+// DumpScaffold walks an already-initialized app directory at devPath and
+// builds the Scaffold manifest that would re-create it, so it can be
+// written out as a single portable file (the inverse of Init).
+func DumpScaffold(devPath string) (s *Scaffold, err error) {
+	dnaPath := filepath.Join(devPath, holo.ChainDNADir)
+	var dnaJSON []byte
+	dnaJSON, err = ioutil.ReadFile(filepath.Join(dnaPath, "dna.json"))
+	if err != nil {
+		return
+	}
+	var dna holo.DNA
+	err = json.Unmarshal(dnaJSON, &dna)
+	if err != nil {
+		return
+	}
+
+	S := Scaffold{
+		Version: ScaffoldVersion,
+		DNA: ScaffoldDNA{
+			Name:       dna.Name,
+			Properties: dna.Properties,
+		},
+	}
+
+	for _, zome := range dna.Zomes {
+		z := ScaffoldZome{
+			Name:        zome.Name,
+			Description: zome.Description,
+			NucleusType: zome.NucleusType,
+		}
+		if zome.CodeFile != "" {
+			var code []byte
+			code, err = ioutil.ReadFile(filepath.Join(dnaPath, zome.CodeFile))
+			if err != nil {
+				return
+			}
+			z.Code = string(code)
+		}
+		for _, e := range zome.Entries {
+			z.Entries = append(z.Entries, ScaffoldEntry{
+				Name:       e.Name,
+				DataFormat: e.DataFormat,
+				Sharing:    e.Sharing,
+				Schema:     e.Schema,
+			})
+		}
+		for _, f := range zome.Functions {
+			z.Functions = append(z.Functions, ScaffoldFunction{
+				Name:        f.Name,
+				CallingType: f.CallingType,
+				Exposure:    f.Exposure,
+			})
+		}
+		S.DNA.Zomes = append(S.DNA.Zomes, z)
+	}
+
+	S.UI, err = dumpScaffoldFiles(filepath.Join(devPath, holo.ChainUIDir))
+	if err != nil {
+		return
+	}
+	S.Test, err = dumpScaffoldFiles(filepath.Join(devPath, holo.ChainTestDir))
+	if err != nil {
+		return
+	}
+
+	s = &S
+	return
+}
+
+func dumpScaffoldFiles(dir string) (files []ScaffoldFile, err error) {
+	_, statErr := os.Stat(dir)
+	if statErr != nil {
+		return
+	}
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, werr error) error {
+		if werr != nil {
+			return werr
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, rerr := filepath.Rel(dir, p)
+		if rerr != nil {
+			return rerr
+		}
+		content, rerr := ioutil.ReadFile(p)
+		if rerr != nil {
+			return rerr
+		}
+		files = append(files, ScaffoldFile{Path: rel, Content: string(content)})
+		return nil
+	})
+	return
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoadScaffoldValid(t *testing.T) {
+	raw := `{
+		"Version": 1,
+		"DNA": {
+			"Name": "myapp",
+			"Zomes": [
+				{"Name": "profile", "NucleusType": "js", "Code": "// code"}
+			]
+		},
+		"UI": [{"Path": "index.html", "Content": "<html></html>"}]
+	}`
+
+	s, err := LoadScaffold(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.DNA.Name != "myapp" {
+		t.Errorf("DNA.Name = %q, want %q", s.DNA.Name, "myapp")
+	}
+	if len(s.DNA.Zomes) != 1 || s.DNA.Zomes[0].Name != "profile" {
+		t.Fatalf("unexpected zomes: %+v", s.DNA.Zomes)
+	}
+	if len(s.UI) != 1 || s.UI[0].Path != "index.html" {
+		t.Fatalf("unexpected UI files: %+v", s.UI)
+	}
+}
+
+func TestLoadScaffoldRejectsNewerVersion(t *testing.T) {
+	raw := `{"Version": ` + strconv.Itoa(ScaffoldVersion+1) + `, "DNA": {"Name": "x", "Zomes": []}}`
+	_, err := LoadScaffold(strings.NewReader(raw))
+	if err == nil {
+		t.Fatal("expected an error for a scaffold version newer than supported, got nil")
+	}
+}
+
+func TestWriteScaffoldFileAndDumpScaffoldFilesRoundTrip(t *testing.T) {
+	base := t.TempDir()
+
+	files := []ScaffoldFile{
+		{Path: "index.html", Content: "<html></html>"},
+		{Path: "css/style.css", Content: "body{}"},
+	}
+	for _, f := range files {
+		if err := writeScaffoldFile(base, f); err != nil {
+			t.Fatalf("writeScaffoldFile(%s): %v", f.Path, err)
+		}
+	}
+
+	got, err := dumpScaffoldFiles(base)
+	if err != nil {
+		t.Fatalf("dumpScaffoldFiles: %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Path < got[j].Path })
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	if len(got) != len(files) {
+		t.Fatalf("got %d files, want %d", len(got), len(files))
+	}
+	for i := range files {
+		if got[i].Path != files[i].Path || got[i].Content != files[i].Content {
+			t.Errorf("file %d = %+v, want %+v", i, got[i], files[i])
+		}
+	}
+}
+
+func TestDumpScaffoldFilesMissingDir(t *testing.T) {
+	files, err := dumpScaffoldFiles("/does/not/exist")
+	if err != nil {
+		t.Fatalf("unexpected error for missing dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files for a missing dir, got %+v", files)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+// native, in-process replacement for the holochain.app.testScenario shell
+// script: spawns one holochain node per role and drives each role's test
+// steps against a shared bootstrap/mDNS discovery setup
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	holo "github.com/metacurrency/holochain"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RoleResult is the outcome of running one role's test steps within a
+// scenario: whether it passed, any errors encountered, and the stdout it
+// produced while running.
+type RoleResult struct {
+	Role   string
+	Passed bool
+	Errors []string
+	Output string
+}
+
+var lastRunContext struct {
+	sync.Mutex
+	results []RoleResult
+}
+
+// stdoutCaptureMu serializes access to the process-global os.Stdout while a
+// role's test output is being redirected into a pipe. Roles otherwise run
+// concurrently, so without this lock two goroutines swapping os.Stdout at
+// the same time can hand each other the wrong "original" value to restore,
+// cross-wiring or dropping output.
+var stdoutCaptureMu sync.Mutex
+
+// GetLastRunContext returns the per-role results of the most recently run
+// scenario, so the Go test suite can assert against them without having to
+// re-parse scenario output.
+func GetLastRunContext() []RoleResult {
+	lastRunContext.Lock()
+	defer lastRunContext.Unlock()
+	return lastRunContext.results
+}
+
+// runScenario spawns one holochain node per role declared in
+// test/<scenario>/roles.json, each with its own agent identity and DHT
+// port, and drives that role's test steps via h.TestScenario. Nodes are
+// set up concurrently unless HC_TESTING is set, in which case they are run
+// in role order to keep timing deterministic. Either way the roles' test
+// steps run one at a time, as each holds the stdout capture while it runs.
+func runScenario(service *holo.Service, devPath, rootPath, cachePath, scenario string, basePort int, useMDNS bool, bootstrapServer string) (results []RoleResult, err error) {
+	scenarioDir := filepath.Join(devPath, holo.ChainTestDir, scenario)
+
+	var rolesJSON []byte
+	rolesJSON, err = ioutil.ReadFile(filepath.Join(scenarioDir, "roles.json"))
+	if err != nil {
+		return
+	}
+	var roles []string
+	err = json.Unmarshal(rolesJSON, &roles)
+	if err != nil {
+		return
+	}
+	if len(roles) == 0 {
+		err = fmt.Errorf("scenario %s: roles.json declares no roles", scenario)
+		return
+	}
+
+	deterministic := os.Getenv("HC_TESTING") != ""
+
+	results = make([]RoleResult, len(roles))
+	var wg sync.WaitGroup
+	for i, role := range roles {
+		run := func(i int, role string) {
+			results[i] = runScenarioRole(service, devPath, rootPath, cachePath, scenario, scenarioDir, role, basePort+i, useMDNS, bootstrapServer)
+		}
+		if deterministic {
+			run(i, role)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, role string) {
+			defer wg.Done()
+			run(i, role)
+		}(i, role)
+	}
+	wg.Wait()
+
+	lastRunContext.Lock()
+	lastRunContext.results = results
+	lastRunContext.Unlock()
+	return
+}
+
+func runScenarioRole(service *holo.Service, devPath, rootPath, cachePath, scenario, scenarioDir, role string, dhtPort int, useMDNS bool, bootstrapServer string) (result RoleResult) {
+	result.Role = role
+
+	roleName := scenario + "." + role
+	nodePath := filepath.Join(cachePath, roleName)
+
+	agent, err := holo.LoadAgent(rootPath)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	agent.SetName(holo.AgentName(roleName + "@scenario.test"))
+
+	err = os.RemoveAll(nodePath)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	err = service.Clone(devPath, nodePath, agent, false)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+
+	h, err := holo.Load(nodePath)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+
+	h.Config.DHTPort = dhtPort
+	h.Config.EnableMDNS = useMDNS
+	h.Config.BootstrapServer = bootstrapServer
+
+	err = h.Activate()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	go h.DHT().HandleGossipWiths()
+	go h.DHT().Gossip(2 * time.Second)
+
+	buf, testErr, testErrs, err := runCapturingStdout(func() (error, []error) {
+		return h.TestScenario(scenarioDir, role)
+	})
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	result.Output = buf
+
+	if testErr != nil {
+		result.Errors = append(result.Errors, testErr.Error())
+	}
+	for _, e := range testErrs {
+		result.Errors = append(result.Errors, e.Error())
+	}
+	result.Passed = len(result.Errors) == 0
+	return
+}
+
+// runCapturingStdout redirects the process-wide os.Stdout into a pipe for
+// the duration of fn, draining that pipe into a buffer concurrently so fn's
+// writes can't fill the pipe's OS buffer and block. Only one goroutine may
+// hold the redirection at a time, so callers running in parallel must go
+// through this rather than swapping os.Stdout themselves.
+func runCapturingStdout(fn func() (error, []error)) (output string, testErr error, testErrs []error, err error) {
+	stdoutCaptureMu.Lock()
+	defer stdoutCaptureMu.Unlock()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	copyDone := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		close(copyDone)
+	}()
+
+	original := os.Stdout
+	os.Stdout = w
+	testErr, testErrs = fn()
+	os.Stdout = original
+
+	w.Close()
+	<-copyDone
+	output = buf.String()
+	return
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGetLastRunContext(t *testing.T) {
+	want := []RoleResult{
+		{Role: "alice", Passed: true},
+		{Role: "bob", Passed: false, Errors: []string{"boom"}},
+	}
+
+	lastRunContext.Lock()
+	lastRunContext.results = want
+	lastRunContext.Unlock()
+
+	got := GetLastRunContext()
+	if len(got) != len(want) {
+		t.Fatalf("GetLastRunContext() returned %d results, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("result %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestRunCapturingStdoutIsolatesConcurrentCallers exercises the race fixed in
+// runCapturingStdout: many goroutines redirecting os.Stdout at once must
+// each get back exactly what they themselves wrote, never another caller's
+// output, and none may block even when a role writes more than a pipe's OS
+// buffer can hold unread.
+func TestRunCapturingStdoutIsolatesConcurrentCallers(t *testing.T) {
+	const callers = 8
+	// bigger than a typical 64KB pipe buffer, to exercise the concurrent drain
+	const linesPerCaller = 20000
+
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			marker := fmt.Sprintf("role-%d", i)
+			output, testErr, testErrs, err := runCapturingStdout(func() (error, []error) {
+				for j := 0; j < linesPerCaller; j++ {
+					fmt.Println(marker)
+				}
+				return nil, nil
+			})
+			if err != nil {
+				t.Errorf("runCapturingStdout: %v", err)
+				return
+			}
+			if testErr != nil || len(testErrs) != 0 {
+				t.Errorf("unexpected fn errors: %v %v", testErr, testErrs)
+			}
+			results[i] = output
+		}(i)
+	}
+	wg.Wait()
+
+	for i, output := range results {
+		marker := fmt.Sprintf("role-%d", i)
+		lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+		if len(lines) != linesPerCaller {
+			t.Fatalf("caller %d: got %d lines, want %d", i, len(lines), linesPerCaller)
+		}
+		for _, line := range lines {
+			if line != marker {
+				t.Fatalf("caller %d: captured foreign output %q, wanted only %q", i, line, marker)
+			}
+		}
+	}
+}
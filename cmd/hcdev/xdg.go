@@ -0,0 +1,120 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+// resolves rootPath/cachePath for the dev service according to the XDG Base
+// Directory spec, migrating a legacy ~/.holochaindev directory forward to
+// the XDG data location on first run
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	xdgAppDirName    = "holochaindev"
+	xdgConfigSubPath = "holochain/config.toml"
+)
+
+// xdgDataHome returns $XDG_DATA_HOME, or its spec default of ~/.local/share
+// when unset.
+func xdgDataHome(home string) string {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return d
+	}
+	return filepath.Join(home, ".local", "share")
+}
+
+// xdgConfigHome returns $XDG_CONFIG_HOME, or its spec default of ~/.config
+// when unset.
+func xdgConfigHome(home string) string {
+	if d := os.Getenv("XDG_CONFIG_HOME"); d != "" {
+		return d
+	}
+	return filepath.Join(home, ".config")
+}
+
+// xdgCacheHome returns $XDG_CACHE_HOME, or its spec default of ~/.cache
+// when unset.
+func xdgCacheHome(home string) string {
+	if d := os.Getenv("XDG_CACHE_HOME"); d != "" {
+		return d
+	}
+	return filepath.Join(home, ".cache")
+}
+
+// resolveRootPath figures out where the dev service's chain data/keys and
+// ephemeral clones live. explicit (the --execpath flag) and $HOLOPATH
+// remain the highest-priority override, for backward compatibility; failing
+// those it follows the XDG Base Directory spec, migrating the legacy
+// ~/.holochaindev directory forward the first time it finds one.
+func resolveRootPath(explicit, home string) (root, cache string, err error) {
+	if explicit != "" {
+		return explicit, explicit, nil
+	}
+	if env := os.Getenv("HOLOPATH"); env != "" {
+		return env, env, nil
+	}
+
+	root = filepath.Join(xdgDataHome(home), xdgAppDirName)
+	cache = filepath.Join(xdgCacheHome(home), xdgAppDirName)
+
+	legacy := filepath.Join(home, "."+xdgAppDirName)
+	if _, statErr := os.Stat(legacy); statErr == nil {
+		if _, rootErr := os.Stat(root); os.IsNotExist(rootErr) {
+			err = migrateLegacyRootPath(legacy, root)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// migrateLegacyRootPath moves the legacy dotfile directory to the XDG data
+// location, logging the move so the user knows where their chain data went.
+func migrateLegacyRootPath(legacy, target string) (err error) {
+	err = os.MkdirAll(filepath.Dir(target), os.ModePerm)
+	if err != nil {
+		return
+	}
+	err = os.Rename(legacy, target)
+	if err != nil {
+		return
+	}
+	fmt.Printf("migrated legacy dev directory %s to %s (XDG Base Directory spec)\n", legacy, target)
+	return
+}
+
+// defaultAgentName reads DefaultAgentName out of
+// $XDG_CONFIG_HOME/holochain/config.toml, if present, so a machine-wide
+// default agent identity can be configured instead of always falling back
+// to "test@example.com". Any error or absence of the key is silently
+// ignored, returning "".
+func defaultAgentName(home string) (name string) {
+	path := filepath.Join(xdgConfigHome(home), xdgConfigSubPath)
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "DefaultAgentName") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		return
+	}
+	return
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2013-2017, The MetaCurrency Project (Eric Harris-Braun, Arthur Brock, et. al.)
+// Use of this source code is governed by GPLv3 found in the LICENSE file
+//---------------------------------------------------------------------------------------
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func clearXDGEnv(t *testing.T) {
+	for _, v := range []string{"XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "HOLOPATH"} {
+		old, had := os.LookupEnv(v)
+		os.Unsetenv(v)
+		t.Cleanup(func() {
+			if had {
+				os.Setenv(v, old)
+			}
+		})
+	}
+}
+
+func TestResolveRootPathExplicitOverride(t *testing.T) {
+	clearXDGEnv(t)
+	root, cache, err := resolveRootPath("/explicit/path", "/home/user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if root != "/explicit/path" || cache != "/explicit/path" {
+		t.Fatalf("expected explicit path to win, got root=%s cache=%s", root, cache)
+	}
+}
+
+func TestResolveRootPathHOLOPATHOverride(t *testing.T) {
+	clearXDGEnv(t)
+	os.Setenv("HOLOPATH", "/holopath/dir")
+	root, cache, err := resolveRootPath("", "/home/user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if root != "/holopath/dir" || cache != "/holopath/dir" {
+		t.Fatalf("expected $HOLOPATH to win, got root=%s cache=%s", root, cache)
+	}
+}
+
+func TestResolveRootPathXDGDefaults(t *testing.T) {
+	clearXDGEnv(t)
+	home := t.TempDir()
+	root, cache, err := resolveRootPath("", home)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantRoot := filepath.Join(home, ".local", "share", xdgAppDirName)
+	wantCache := filepath.Join(home, ".cache", xdgAppDirName)
+	if root != wantRoot {
+		t.Errorf("root = %s, want %s", root, wantRoot)
+	}
+	if cache != wantCache {
+		t.Errorf("cache = %s, want %s", cache, wantCache)
+	}
+}
+
+func TestResolveRootPathMigratesLegacyDir(t *testing.T) {
+	clearXDGEnv(t)
+	home := t.TempDir()
+	legacy := filepath.Join(home, "."+xdgAppDirName)
+	if err := os.MkdirAll(legacy, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(legacy, "keys.txt")
+	if err := os.WriteFile(marker, []byte("secret"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	root, _, err := resolveRootPath("", home)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("expected legacy dir %s to be migrated away, still exists", legacy)
+	}
+	migrated := filepath.Join(root, "keys.txt")
+	content, err := os.ReadFile(migrated)
+	if err != nil {
+		t.Fatalf("expected migrated file at %s: %v", migrated, err)
+	}
+	if string(content) != "secret" {
+		t.Errorf("migrated file content = %q, want %q", content, "secret")
+	}
+}
+
+func TestDefaultAgentName(t *testing.T) {
+	clearXDGEnv(t)
+	home := t.TempDir()
+
+	if got := defaultAgentName(home); got != "" {
+		t.Fatalf("expected empty agent name with no config.toml, got %q", got)
+	}
+
+	configDir := filepath.Join(home, ".config", "holochain")
+	if err := os.MkdirAll(configDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	config := "# comment\nDefaultAgentName = \"alice@example.com\"\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(config), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := defaultAgentName(home); got != "alice@example.com" {
+		t.Errorf("defaultAgentName = %q, want %q", got, "alice@example.com")
+	}
+}